@@ -0,0 +1,561 @@
+// Package authorization parses the `# Dgraph.Authorization` directive that
+// can be embedded in a GraphQL schema, and verifies the JWTs that requests
+// present against the key material (or JWKS endpoint) it describes.
+package authorization
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// wrapf annotates err with a formatted message, the way github.com/pkg/errors
+// Wrapf used to — written against the standard library so this package has
+// no third-party dependencies of its own.
+func wrapf(err error, format string, args ...interface{}) error {
+	return fmt.Errorf(format+": %w", append(args, err)...)
+}
+
+// directiveRegexp finds the `# Dgraph.Authorization <json>` line anywhere in
+// a schema file. The directive is a regular GraphQL comment, so it can sit
+// anywhere the schema author likes.
+var directiveRegexp = regexp.MustCompile(`(?m)^\s*#\s*Dgraph\.Authorization\s+(\{.*\})\s*$`)
+
+// rawAuthMeta is the on-the-wire JSON shape of the directive.
+type rawAuthMeta struct {
+	VerificationKey string   `json:"VerificationKey"`
+	JWKURL          string   `json:"JWKURL"`
+	JWKURLs         []string `json:"JWKURLs"`
+	Header          string   `json:"Header"`
+	Namespace       string   `json:"Namespace"`
+	NamespaceField  string   `json:"NamespaceField"`
+	Algo            string   `json:"Algo"`
+	Audience        []string `json:"Audience"`
+	ClosingTimeSkew int      `json:"ClosingTimeSkew"`
+}
+
+// AuthMeta is the parsed, ready-to-use form of the `# Dgraph.Authorization`
+// directive. A single AuthMeta is shared across every request that hits a
+// given schema, so the JWKS cache on it is guarded by a mutex.
+type AuthMeta struct {
+	VerificationKey string
+	JWKURL          string
+	JWKURLs         []string
+	Header          string
+	Namespace       uint64
+	NamespaceField  string
+	Algo            string
+	Audience        []string
+	ClosingTimeSkew time.Duration
+
+	mu         sync.RWMutex
+	keysByKID  map[string]interface{}
+	keyExpiry  time.Time
+	httpClient *http.Client
+}
+
+// Parse reads the `# Dgraph.Authorization` directive out of schema and
+// returns the AuthMeta it describes. It does not fetch a JWKS keyset eagerly
+// — that happens lazily, the first time a token with an unrecognised `kid`
+// needs verifying.
+func Parse(schema string) (*AuthMeta, error) {
+	matches := directiveRegexp.FindStringSubmatch(schema)
+	if matches == nil {
+		// No auth directive: callers treat an empty, non-error AuthMeta as
+		// "this schema has no auth rules".
+		return &AuthMeta{}, nil
+	}
+
+	var raw rawAuthMeta
+	if err := json.Unmarshal([]byte(matches[1]), &raw); err != nil {
+		return nil, wrapf(err, "unable to parse Dgraph.Authorization directive")
+	}
+
+	var namespace uint64
+	if raw.Namespace != "" {
+		ns, err := strconv.ParseUint(raw.Namespace, 0, 64)
+		if err != nil {
+			return nil, wrapf(err, "invalid Namespace %q in Dgraph.Authorization directive",
+				raw.Namespace)
+		}
+		namespace = ns
+	}
+
+	header := raw.Header
+	if header == "" {
+		header = "Authorization"
+	}
+
+	if raw.VerificationKey == "" && raw.JWKURL == "" && len(raw.JWKURLs) == 0 {
+		return nil, errors.New("Dgraph.Authorization directive must set VerificationKey, JWKURL or JWKURLs")
+	}
+
+	return &AuthMeta{
+		VerificationKey: raw.VerificationKey,
+		JWKURL:          raw.JWKURL,
+		JWKURLs:         raw.JWKURLs,
+		Header:          header,
+		Namespace:       namespace,
+		NamespaceField:  raw.NamespaceField,
+		Algo:            raw.Algo,
+		Audience:        raw.Audience,
+		ClosingTimeSkew: time.Duration(raw.ClosingTimeSkew) * time.Second,
+		httpClient:      http.DefaultClient,
+	}, nil
+}
+
+// usesJWKS reports whether keys should come from a JWKS endpoint rather than
+// the static VerificationKey.
+func (a *AuthMeta) usesJWKS() bool {
+	return a.JWKURL != "" || len(a.JWKURLs) > 0
+}
+
+// jwkURLs returns every JWKS endpoint configured, JWKURL first, so a failure
+// to fetch the primary IdP's keyset falls back to the others. Only the keyset
+// of the first endpoint that answers is kept; the others are not searched for
+// a kid it lacks.
+func (a *AuthMeta) jwkURLs() []string {
+	if a.JWKURL == "" {
+		return a.JWKURLs
+	}
+	return append([]string{a.JWKURL}, a.JWKURLs...)
+}
+
+// VerificationKeyForKID returns the public key (or HMAC secret) that should
+// verify a token carrying the given kid. For a statically-configured
+// VerificationKey, kid is ignored. For a JWKS-backed AuthMeta, the cached
+// keyset is consulted first; an unrecognised kid triggers a synchronous
+// re-fetch, since that's the common signature of a key having just rotated.
+func (a *AuthMeta) VerificationKeyForKID(kid string) (interface{}, error) {
+	if !a.usesJWKS() {
+		return a.parseStaticKey()
+	}
+
+	a.mu.RLock()
+	key, ok := a.keysByKID[kid]
+	fresh := ok && time.Now().Before(a.keyExpiry)
+	a.mu.RUnlock()
+	if fresh {
+		return key, nil
+	}
+
+	if err := a.refreshKeySet(); err != nil {
+		return nil, err
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	key, ok = a.keysByKID[kid]
+	if !ok {
+		return nil, fmt.Errorf("no JWKS key found for kid %q", kid)
+	}
+	return key, nil
+}
+
+// jwk is the JSON shape of a single entry in a JWKS `keys` array. Only the
+// fields needed to rebuild an RSA, EC or OKP public key are modelled;
+// unsupported key types are skipped rather than erroring the whole fetch.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jwkSet struct {
+	Keys []jwk `json:"keys"`
+}
+
+// refreshKeySet re-fetches the keyset from the first JWKURL that answers,
+// honouring the response's Cache-Control max-age for the next refresh.
+func (a *AuthMeta) refreshKeySet() error {
+	client := a.httpClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	var lastErr error
+	for _, url := range a.jwkURLs() {
+		keys, ttl, err := fetchJWKS(client, url)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		a.mu.Lock()
+		a.keysByKID = keys
+		a.keyExpiry = time.Now().Add(ttl)
+		a.mu.Unlock()
+		return nil
+	}
+	return wrapf(lastErr, "unable to fetch JWKS from any configured URL")
+}
+
+func fetchJWKS(client *http.Client, url string) (map[string]interface{}, time.Duration, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("JWKS endpoint %s returned status %d", url, resp.StatusCode)
+	}
+
+	var set jwkSet
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, 0, wrapf(err, "unable to decode JWKS from %s", url)
+	}
+
+	keys := make(map[string]interface{})
+	for _, k := range set.Keys {
+		key, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = key
+	}
+
+	return keys, cacheTTL(resp.Header.Get("Cache-Control")), nil
+}
+
+// defaultJWKSTTL is used whenever the JWKS response doesn't set a
+// Cache-Control max-age, so a misbehaving IdP doesn't pin us to a stale
+// keyset forever.
+const defaultJWKSTTL = 5 * time.Minute
+
+var maxAgeRegexp = regexp.MustCompile(`max-age=(\d+)`)
+
+func cacheTTL(cacheControl string) time.Duration {
+	m := maxAgeRegexp.FindStringSubmatch(cacheControl)
+	if m == nil {
+		return defaultJWKSTTL
+	}
+	seconds, err := strconv.Atoi(m[1])
+	if err != nil || seconds <= 0 {
+		return defaultJWKSTTL
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func (k jwk) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		nBytes, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		eBytes, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		n := new(big.Int).SetBytes(nBytes)
+		e := new(big.Int).SetBytes(eBytes)
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		curve, err := curveForName(k.Crv)
+		if err != nil {
+			return nil, err
+		}
+		xBytes, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		yBytes, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(xBytes),
+			Y:     new(big.Int).SetBytes(yBytes),
+		}, nil
+	case "OKP":
+		xBytes, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		return ed25519.PublicKey(xBytes), nil
+	default:
+		return nil, fmt.Errorf("unsupported JWKS key type %q", k.Kty)
+	}
+}
+
+// parseStaticKey parses a.VerificationKey according to a.Algo: a plain HMAC
+// secret for HS256, otherwise a PEM-encoded public key parsed via PKIX —
+// which covers RSA, ECDSA (ES256/ES384/ES512) and Ed25519 (EdDSA) public
+// keys alike, since Go's x509 PKIX decoder handles all three.
+func (a *AuthMeta) parseStaticKey() (interface{}, error) {
+	if a.Algo == "HS256" {
+		return []byte(a.VerificationKey), nil
+	}
+
+	block, _ := pem.Decode([]byte(a.VerificationKey))
+	if block == nil {
+		return nil, fmt.Errorf("VerificationKey for algo %s is not a valid PEM block", a.Algo)
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, wrapf(err, "unable to parse PKIX public key for algo %s", a.Algo)
+	}
+	return key, nil
+}
+
+// VerifyToken is the package's single entry point for authenticating a
+// request: it decodes tokenString's header and payload, resolves the key for
+// its `kid` (or the static VerificationKey, for a non-JWKS AuthMeta), checks
+// the signature, rejects an expired or not-yet-valid token, and enforces the
+// namespace claim against connNamespace. It returns the token's claims so the
+// caller can evaluate `@auth` rules against them.
+func (a *AuthMeta) VerifyToken(tokenString string, connNamespace uint64) (map[string]interface{}, error) {
+	parts := strings.Split(tokenString, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("token is not a valid compact JWT")
+	}
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, wrapf(err, "unable to decode token header")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return nil, wrapf(err, "unable to parse token header")
+	}
+
+	algo := header.Alg
+	if a.Algo != "" && algo != a.Algo {
+		return nil, fmt.Errorf("token alg %q does not match schema's configured algo %q", algo, a.Algo)
+	}
+
+	key, err := a.VerificationKeyForKID(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, wrapf(err, "unable to decode token signature")
+	}
+	signingInput := parts[0] + "." + parts[1]
+	if err := VerifySignature(algo, key, []byte(signingInput), signature); err != nil {
+		return nil, wrapf(err, "token signature verification failed")
+	}
+
+	payloadJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, wrapf(err, "unable to decode token claims")
+	}
+	var claims map[string]interface{}
+	if err := json.Unmarshal(payloadJSON, &claims); err != nil {
+		return nil, wrapf(err, "unable to parse token claims")
+	}
+
+	if err := a.checkTimeClaims(claims); err != nil {
+		return nil, err
+	}
+
+	if _, err := a.ExpectedNamespace(claims[a.NamespaceField], connNamespace); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// checkTimeClaims enforces the standard `exp` and `nbf` claims, when present,
+// against the current time. ClosingTimeSkew widens both checks by the same
+// amount, so a little clock drift between the token issuer and this server
+// doesn't reject a token that's genuinely still valid.
+func (a *AuthMeta) checkTimeClaims(claims map[string]interface{}) error {
+	now := time.Now()
+
+	if raw, ok := claims["exp"]; ok {
+		exp, err := parseTimeClaim(raw)
+		if err != nil {
+			return wrapf(err, "invalid exp claim")
+		}
+		if now.After(exp.Add(a.ClosingTimeSkew)) {
+			return fmt.Errorf("token expired at %s", exp)
+		}
+	}
+
+	if raw, ok := claims["nbf"]; ok {
+		nbf, err := parseTimeClaim(raw)
+		if err != nil {
+			return wrapf(err, "invalid nbf claim")
+		}
+		if now.Before(nbf.Add(-a.ClosingTimeSkew)) {
+			return fmt.Errorf("token is not valid until %s", nbf)
+		}
+	}
+
+	return nil
+}
+
+// parseTimeClaim reads a JWT NumericDate claim (exp/nbf/iat): a Unix
+// timestamp, encoded as the JSON number `json.Unmarshal` gives us, or
+// (tolerated, since some issuers stringify it) as a numeric string.
+func parseTimeClaim(raw interface{}) (time.Time, error) {
+	switch v := raw.(type) {
+	case float64:
+		return time.Unix(int64(v), 0), nil
+	case string:
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("non-numeric value %q", v)
+		}
+		return time.Unix(n, 0), nil
+	default:
+		return time.Time{}, fmt.Errorf("unsupported claim type %T", v)
+	}
+}
+
+// ExpectedNamespace resolves the namespace a token claims to belong to,
+// given the value of its NamespaceField claim, and checks it against the
+// namespace the connection is actually talking to. A mismatch must be
+// rejected: otherwise a token valid for one tenant could be replayed
+// against another.
+func (a *AuthMeta) ExpectedNamespace(claimValue interface{}, connNamespace uint64) (uint64, error) {
+	if a.NamespaceField == "" {
+		return connNamespace, nil
+	}
+
+	var claimed uint64
+	switch v := claimValue.(type) {
+	case nil:
+		return 0, fmt.Errorf("token is missing required namespace claim %q", a.NamespaceField)
+	case float64:
+		claimed = uint64(v)
+	case string:
+		n, err := strconv.ParseUint(v, 0, 64)
+		if err != nil {
+			return 0, wrapf(err, "namespace claim %q has non-numeric value %q", a.NamespaceField, v)
+		}
+		claimed = n
+	default:
+		return 0, fmt.Errorf("namespace claim %q has unsupported type %T", a.NamespaceField, v)
+	}
+
+	if claimed != connNamespace {
+		return 0, fmt.Errorf(
+			"token is bound to namespace %d but was presented against namespace %d", claimed, connNamespace)
+	}
+	return claimed, nil
+}
+
+// VerifySignature checks signingInput's signature using key and the named
+// algorithm. It exists so both the production verifier and the test JWT
+// minter in testutil share exactly one notion of what each algorithm's
+// signature looks like.
+func VerifySignature(algo string, key interface{}, signingInput, signature []byte) error {
+	switch algo {
+	case "HS256":
+		secret, ok := key.([]byte)
+		if !ok {
+			return fmt.Errorf("HS256 requires an HMAC secret, got %T", key)
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(signingInput)
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return errors.New("HS256 signature mismatch")
+		}
+		return nil
+	case "RS256":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("RS256 requires an RSA public key, got %T", key)
+		}
+		sum := sha256.Sum256(signingInput)
+		return rsa.VerifyPKCS1v15(pub, crypto.SHA256, sum[:], signature)
+	case "PS256":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("PS256 requires an RSA public key, got %T", key)
+		}
+		sum := sha256.Sum256(signingInput)
+		return rsa.VerifyPSS(pub, crypto.SHA256, sum[:], signature, nil)
+	case "ES256", "ES384", "ES512":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%s requires an ECDSA public key, got %T", algo, key)
+		}
+		return verifyECDSA(algo, pub, signingInput, signature)
+	case "EdDSA":
+		pub, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return fmt.Errorf("EdDSA requires an Ed25519 public key, got %T", key)
+		}
+		if !ed25519.Verify(pub, signingInput, signature) {
+			return errors.New("EdDSA signature mismatch")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported signing algorithm %q", algo)
+	}
+}
+
+func verifyECDSA(algo string, pub *ecdsa.PublicKey, signingInput, signature []byte) error {
+	var sum []byte
+	var keyBytes int
+	switch algo {
+	case "ES256":
+		h := sha256.Sum256(signingInput)
+		sum, keyBytes = h[:], 32
+	case "ES384":
+		h := sha512.Sum384(signingInput)
+		sum, keyBytes = h[:], 48
+	case "ES512":
+		h := sha512.Sum512(signingInput)
+		sum, keyBytes = h[:], 66
+	default:
+		return fmt.Errorf("unsupported ECDSA algorithm %q", algo)
+	}
+
+	if len(signature) != 2*keyBytes {
+		return fmt.Errorf("%s signature has unexpected length %d", algo, len(signature))
+	}
+	r := new(big.Int).SetBytes(signature[:keyBytes])
+	s := new(big.Int).SetBytes(signature[keyBytes:])
+	if !ecdsa.Verify(pub, sum, r, s) {
+		return fmt.Errorf("%s signature mismatch", algo)
+	}
+	return nil
+}
+
+func curveForName(name string) (elliptic.Curve, error) {
+	switch name {
+	case "P-256":
+		return elliptic.P256(), nil
+	case "P-384":
+		return elliptic.P384(), nil
+	case "P-521":
+		return elliptic.P521(), nil
+	default:
+		return nil, fmt.Errorf("unsupported EC curve %q", name)
+	}
+}
@@ -0,0 +1,389 @@
+package authorization
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func mustPEM(t *testing.T, pub interface{}) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatalf("marshalling public key: %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func TestParse(t *testing.T) {
+	schema := `
+type Query {
+  hello: String
+}
+# Dgraph.Authorization {"VerificationKey":"secret","Header":"X-Test-Auth","Namespace":"0","Algo":"HS256"}
+`
+	meta, err := Parse(schema)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	if meta.VerificationKey != "secret" || meta.Header != "X-Test-Auth" || meta.Algo != "HS256" {
+		t.Fatalf("unexpected AuthMeta: %+v", meta)
+	}
+}
+
+func TestParseNoDirective(t *testing.T) {
+	meta, err := Parse(`type Query { hello: String }`)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	if meta.VerificationKey != "" {
+		t.Fatalf("expected empty AuthMeta, got %+v", meta)
+	}
+}
+
+func TestParseMissingKeySource(t *testing.T) {
+	_, err := Parse(`# Dgraph.Authorization {"Header":"X-Test-Auth","Algo":"HS256"}`)
+	if err == nil {
+		t.Fatal("expected an error when VerificationKey isn't set")
+	}
+}
+
+// ecdsaSign produces a JWT-style r||s signature (not ASN.1 DER) for the
+// given algorithm, matching what VerifySignature expects.
+func ecdsaSign(t *testing.T, algo string, priv *ecdsa.PrivateKey, signingInput []byte) []byte {
+	t.Helper()
+	var sum []byte
+	var keyBytes int
+	switch algo {
+	case "ES256":
+		h := sha256.Sum256(signingInput)
+		sum, keyBytes = h[:], 32
+	case "ES384":
+		h := sha512.Sum384(signingInput)
+		sum, keyBytes = h[:], 48
+	case "ES512":
+		h := sha512.Sum512(signingInput)
+		sum, keyBytes = h[:], 66
+	}
+	r, s, err := ecdsa.Sign(rand.Reader, priv, sum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := make([]byte, 2*keyBytes)
+	r.FillBytes(sig[:keyBytes])
+	s.FillBytes(sig[keyBytes:])
+	return sig
+}
+
+const shaHash = crypto.SHA256
+
+func TestVerifySignatureEachAlgo(t *testing.T) {
+	signingInput := []byte("header.payload")
+
+	t.Run("HS256", func(t *testing.T) {
+		secret := []byte("shared-secret")
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(signingInput)
+		sig := mac.Sum(nil)
+		if err := VerifySignature("HS256", secret, signingInput, sig); err != nil {
+			t.Fatalf("valid signature rejected: %v", err)
+		}
+		if err := VerifySignature("HS256", secret, []byte("tampered"), sig); err == nil {
+			t.Fatal("signature over different input incorrectly accepted")
+		}
+	})
+
+	t.Run("RS256", func(t *testing.T) {
+		priv, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum := sha256.Sum256(signingInput)
+		sig, err := rsa.SignPKCS1v15(rand.Reader, priv, shaHash, sum[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifySignature("RS256", &priv.PublicKey, signingInput, sig); err != nil {
+			t.Fatalf("valid signature rejected: %v", err)
+		}
+	})
+
+	t.Run("PS256", func(t *testing.T) {
+		priv, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum := sha256.Sum256(signingInput)
+		sig, err := rsa.SignPSS(rand.Reader, priv, shaHash, sum[:], nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifySignature("PS256", &priv.PublicKey, signingInput, sig); err != nil {
+			t.Fatalf("valid signature rejected: %v", err)
+		}
+	})
+
+	for algo, curve := range map[string]elliptic.Curve{
+		"ES256": elliptic.P256(),
+		"ES384": elliptic.P384(),
+		"ES512": elliptic.P521(),
+	} {
+		algo, curve := algo, curve
+		t.Run(algo, func(t *testing.T) {
+			priv, err := ecdsa.GenerateKey(curve, rand.Reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sig := ecdsaSign(t, algo, priv, signingInput)
+			if err := VerifySignature(algo, &priv.PublicKey, signingInput, sig); err != nil {
+				t.Fatalf("valid signature rejected: %v", err)
+			}
+			if err := VerifySignature(algo, &priv.PublicKey, []byte("tampered"), sig); err == nil {
+				t.Fatal("signature over different input incorrectly accepted")
+			}
+		})
+	}
+
+	t.Run("EdDSA", func(t *testing.T) {
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig := ed25519.Sign(priv, signingInput)
+		if err := VerifySignature("EdDSA", pub, signingInput, sig); err != nil {
+			t.Fatalf("valid signature rejected: %v", err)
+		}
+	})
+}
+
+func TestParseStaticKeyPKIX(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta := &AuthMeta{Algo: "ES256", VerificationKey: mustPEM(t, &priv.PublicKey)}
+	key, err := meta.parseStaticKey()
+	if err != nil {
+		t.Fatalf("parseStaticKey: %v", err)
+	}
+	if _, ok := key.(*ecdsa.PublicKey); !ok {
+		t.Fatalf("expected *ecdsa.PublicKey, got %T", key)
+	}
+}
+
+func TestJWKSKeyRotation(t *testing.T) {
+	key1, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key2, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := map[string]*rsa.PublicKey{"kid-1": &key1.PublicKey}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=1")
+		fmt.Fprint(w, jwksJSON(keys))
+	}))
+	defer server.Close()
+
+	meta := &AuthMeta{Algo: "RS256", JWKURL: server.URL}
+
+	if _, err := meta.VerificationKeyForKID("kid-1"); err != nil {
+		t.Fatalf("expected to resolve the initially published key, got: %v", err)
+	}
+
+	// Rotate: the IdP now serves a second key under a kid we've never seen.
+	keys["kid-2"] = &key2.PublicKey
+	key, err := meta.VerificationKeyForKID("kid-2")
+	if err != nil {
+		t.Fatalf("expected a lazy re-fetch to pick up the rotated key, got: %v", err)
+	}
+	if key.(*rsa.PublicKey).N.Cmp(key2.PublicKey.N) != 0 {
+		t.Fatal("resolved key does not match the rotated key")
+	}
+}
+
+func TestExpectedNamespaceMismatch(t *testing.T) {
+	meta := &AuthMeta{NamespaceField: "https://dgraph.io/jwt/namespace"}
+
+	if _, err := meta.ExpectedNamespace(float64(1), 2); err == nil {
+		t.Fatal("expected a namespace mismatch error")
+	}
+	ns, err := meta.ExpectedNamespace(float64(2), 2)
+	if err != nil || ns != 2 {
+		t.Fatalf("expected namespace 2 with no error, got ns=%d err=%v", ns, err)
+	}
+}
+
+// signHS256 mints a compact JWT signed with secret, in the wire format
+// VerifyToken expects to decode.
+func signHS256(t *testing.T, secret []byte, kid string, claims map[string]interface{}) string {
+	t.Helper()
+	header := map[string]interface{}{"alg": "HS256", "typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." +
+		base64.RawURLEncoding.EncodeToString(claimsJSON)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signingInput))
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyTokenEndToEnd(t *testing.T) {
+	secret := []byte("shared-secret")
+	meta := &AuthMeta{
+		Algo:            "HS256",
+		VerificationKey: string(secret),
+		NamespaceField:  "https://dgraph.io/jwt/namespace",
+	}
+
+	token := signHS256(t, secret, "", map[string]interface{}{
+		"USER":                            "alice",
+		"https://dgraph.io/jwt/namespace": float64(1),
+	})
+
+	claims, err := meta.VerifyToken(token, 1)
+	if err != nil {
+		t.Fatalf("valid token rejected: %v", err)
+	}
+	if claims["USER"] != "alice" {
+		t.Fatalf("unexpected claims: %+v", claims)
+	}
+
+	if _, err := meta.VerifyToken(token, 2); err == nil {
+		t.Fatal("expected VerifyToken to reject a namespace mismatch")
+	}
+
+	tampered := token[:len(token)-1] + "x"
+	if _, err := meta.VerifyToken(tampered, 1); err == nil {
+		t.Fatal("expected VerifyToken to reject a tampered signature")
+	}
+}
+
+func TestVerifyTokenRejectsExpiredToken(t *testing.T) {
+	secret := []byte("shared-secret")
+	meta := &AuthMeta{Algo: "HS256", VerificationKey: string(secret)}
+
+	token := signHS256(t, secret, "", map[string]interface{}{
+		"USER": "alice",
+		"exp":  float64(time.Now().Add(-time.Minute).Unix()),
+	})
+	if _, err := meta.VerifyToken(token, 0); err == nil {
+		t.Fatal("expected an expired token to be rejected")
+	}
+
+	meta.ClosingTimeSkew = 2 * time.Minute
+	if _, err := meta.VerifyToken(token, 0); err != nil {
+		t.Fatalf("expected ClosingTimeSkew to tolerate a 1-minute-expired token, got: %v", err)
+	}
+}
+
+func TestVerifyTokenRejectsNotYetValidToken(t *testing.T) {
+	secret := []byte("shared-secret")
+	meta := &AuthMeta{Algo: "HS256", VerificationKey: string(secret)}
+
+	token := signHS256(t, secret, "", map[string]interface{}{
+		"USER": "alice",
+		"nbf":  float64(time.Now().Add(time.Minute).Unix()),
+	})
+	if _, err := meta.VerifyToken(token, 0); err == nil {
+		t.Fatal("expected a not-yet-valid token to be rejected")
+	}
+}
+
+func TestVerifyTokenAcceptsUnexpiredToken(t *testing.T) {
+	secret := []byte("shared-secret")
+	meta := &AuthMeta{Algo: "HS256", VerificationKey: string(secret)}
+
+	token := signHS256(t, secret, "", map[string]interface{}{
+		"USER": "alice",
+		"exp":  float64(time.Now().Add(time.Hour).Unix()),
+	})
+	if _, err := meta.VerifyToken(token, 0); err != nil {
+		t.Fatalf("valid, unexpired token rejected: %v", err)
+	}
+}
+
+func TestVerifyTokenJWKS(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := map[string]*rsa.PublicKey{"kid-1": &priv.PublicKey}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=1")
+		fmt.Fprint(w, jwksJSON(keys))
+	}))
+	defer server.Close()
+
+	meta := &AuthMeta{Algo: "RS256", JWKURL: server.URL}
+
+	header := map[string]interface{}{"alg": "RS256", "typ": "JWT", "kid": "kid-1"}
+	headerJSON, _ := json.Marshal(header)
+	claimsJSON, _ := json.Marshal(map[string]interface{}{"USER": "bob"})
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." +
+		base64.RawURLEncoding.EncodeToString(claimsJSON)
+	sum := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, priv, shaHash, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := signingInput + "." + base64.RawURLEncoding.EncodeToString(sig)
+
+	claims, err := meta.VerifyToken(token, 0)
+	if err != nil {
+		t.Fatalf("valid JWKS-backed token rejected: %v", err)
+	}
+	if claims["USER"] != "bob" {
+		t.Fatalf("unexpected claims: %+v", claims)
+	}
+}
+
+func jwksJSON(keys map[string]*rsa.PublicKey) string {
+	entries := make([]string, 0, len(keys))
+	for kid, pub := range keys {
+		entries = append(entries, fmt.Sprintf(
+			`{"kty":"RSA","use":"sig","alg":"RS256","kid":%q,"n":%q,"e":%q}`,
+			kid,
+			base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		))
+	}
+	out := "{\"keys\":["
+	for i, e := range entries {
+		if i > 0 {
+			out += ","
+		}
+		out += e
+	}
+	return out + "]}"
+}
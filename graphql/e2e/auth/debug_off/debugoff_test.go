@@ -3,7 +3,12 @@
 package debugoff
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
 	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 
@@ -64,7 +69,7 @@ func TestAddGQL(t *testing.T) {
 
 	for _, tcase := range testCases {
 		getUserParams := &common.GraphQLParams{
-			Headers:   common.GetJWT(t, tcase.user, tcase.role, metaInfo),
+			Headers:   common.GetJWT(t, tcase.user, tcase.role, metaInfo.Namespace, metaInfo),
 			Query:     query,
 			Variables: tcase.variables,
 		}
@@ -87,39 +92,201 @@ func TestAddGQL(t *testing.T) {
 			i.Delete(t, tcase.user, tcase.role, metaInfo)
 		}
 	}
+
+	// A mutation that omits a field carrying a schema default (e.g.
+	// `visibility: String = "private"` on `UserSecret`) must have that
+	// default materialized rather than leaving the field null.
+	defaultsQuery := `
+		mutation addUser($user: AddUserSecretInput!) {
+			addUserSecret(input: [$user]) {
+				userSecret {
+					aSecret
+					visibility
+				}
+			}
+		}
+	`
+	defaultsParams := &common.GraphQLParams{
+		Headers: common.GetJWT(t, "user1", "", metaInfo.Namespace, metaInfo),
+		Query:   defaultsQuery,
+		Variables: map[string]interface{}{"user": &common.UserSecret{
+			ASecret: "secret-with-default",
+			OwnedBy: "user1",
+		}},
+	}
+	gqlResponse := defaultsParams.ExecuteAsPost(t, common.GraphqlURL)
+	common.RequireNoGQLErrors(t, gqlResponse)
+
+	var defaultsResult struct {
+		AddUserSecret struct {
+			UserSecret []*common.UserSecret
+		}
+	}
+	require.NoError(t, json.Unmarshal(gqlResponse.Data, &defaultsResult))
+	require.Len(t, defaultsResult.AddUserSecret.UserSecret, 1)
+	require.Equal(t, "private", defaultsResult.AddUserSecret.UserSecret[0].Visibility)
+
+	for _, i := range defaultsResult.AddUserSecret.UserSecret {
+		i.Delete(t, "user1", "", metaInfo)
+	}
 }
 
 func TestAddMutationWithXid(t *testing.T) {
 	mutation := `
-	mutation addTweets($tweet: AddTweetsInput!){
-      addTweets(input: [$tweet]) {
+	mutation addTweets($tweet: AddTweetsInput!, $conflict: ConflictMode){
+      addTweets(input: [$tweet], conflict: $conflict) {
         numUids
       }
     }
 	`
 
-	tweet := common.Tweets{
-		Id:        "tweet1",
-		Text:      "abc",
-		Timestamp: "2020-10-10",
+	newTweet := func(text string) common.Tweets {
+		return common.Tweets{
+			Id:        "tweet1",
+			Text:      text,
+			Timestamp: "2020-10-10",
+		}
 	}
-	user := "foo"
-	addTweetsParams := &common.GraphQLParams{
-		Headers:   common.GetJWT(t, user, "", metaInfo),
-		Query:     mutation,
-		Variables: map[string]interface{}{"tweet": tweet},
+
+	addTweet := func(t *testing.T, user, conflict string, tweet common.Tweets) *common.GraphQLResponse {
+		params := &common.GraphQLParams{
+			Headers: common.GetJWT(t, user, "", metaInfo.Namespace, metaInfo),
+			Query:   mutation,
+			Variables: map[string]interface{}{
+				"tweet":    tweet,
+				"conflict": conflict,
+			},
+		}
+		return params.ExecuteAsPost(t, common.GraphqlURL)
 	}
 
-	// Add the tweet for the first time.
-	gqlResponse := addTweetsParams.ExecuteAsPost(t, common.GraphqlURL)
-	common.RequireNoGQLErrors(t, gqlResponse)
+	user := "foo"
 
-	// Re-adding the tweet should fail.
-	gqlResponse = addTweetsParams.ExecuteAsPost(t, common.GraphqlURL)
+	// ERROR: the default-equivalent mode must surface the xid clash as a
+	// GraphQL error instead of silently accepting the no-op.
+	t.Run("conflict mode ERROR rejects a duplicate xid", func(t *testing.T) {
+		gqlResponse := addTweet(t, user, "ERROR", newTweet("abc"))
+		common.RequireNoGQLErrors(t, gqlResponse)
+
+		gqlResponse = addTweet(t, user, "ERROR", newTweet("abc"))
+		require.NotEqual(t, 0, len(gqlResponse.Errors))
+
+		tweet := newTweet("abc")
+		tweet.DeleteByID(t, user, metaInfo)
+	})
+
+	// IGNORE: re-adding the same xid is a deliberate no-op, with no error
+	// and no uid churn.
+	t.Run("conflict mode IGNORE is a silent no-op", func(t *testing.T) {
+		gqlResponse := addTweet(t, user, "IGNORE", newTweet("abc"))
+		common.RequireNoGQLErrors(t, gqlResponse)
+
+		gqlResponse = addTweet(t, user, "IGNORE", newTweet("xyz"))
+		common.RequireNoGQLErrors(t, gqlResponse)
+
+		var result struct {
+			AddTweets struct {
+				NumUids int
+			}
+		}
+		require.NoError(t, json.Unmarshal(gqlResponse.Data, &result))
+		require.Equal(t, 0, result.AddTweets.NumUids)
+
+		tweet := newTweet("abc")
+		tweet.DeleteByID(t, user, metaInfo)
+	})
+
+	// UPDATE as the owner: the caller's `update` auth rule is satisfied, so
+	// the existing node is updated in place.
+	t.Run("conflict mode UPDATE succeeds for an authorized caller", func(t *testing.T) {
+		gqlResponse := addTweet(t, user, "UPDATE", newTweet("abc"))
+		common.RequireNoGQLErrors(t, gqlResponse)
+
+		gqlResponse = addTweet(t, user, "UPDATE", newTweet("abc-updated"))
+		common.RequireNoGQLErrors(t, gqlResponse)
+
+		tweet := newTweet("abc")
+		tweet.DeleteByID(t, user, metaInfo)
+	})
+
+	// UPDATE as a different caller: the existing node fails that caller's
+	// `update` auth rule, so the conflict must be rejected with a GraphQL
+	// error rather than silently dropped.
+	t.Run("conflict mode UPDATE is rejected for an unauthorized caller", func(t *testing.T) {
+		gqlResponse := addTweet(t, user, "UPDATE", newTweet("abc"))
+		common.RequireNoGQLErrors(t, gqlResponse)
+
+		gqlResponse = addTweet(t, "bar", "UPDATE", newTweet("abc-hijacked"))
+		require.NotEqual(t, 0, len(gqlResponse.Errors))
+
+		tweet := newTweet("abc")
+		tweet.DeleteByID(t, user, metaInfo)
+	})
+}
+
+// TestNamespaceIsolation proves that the namespace claim bound via
+// AuthMeta.NamespaceField is enforced independently of the other auth
+// claims: a token that otherwise identifies the same user/owner pair must
+// not be able to reach a row that belongs to a different namespace.
+func TestNamespaceIsolation(t *testing.T) {
+	const ns1, ns2 = uint64(1), uint64(2)
+
+	addMutation := `
+		mutation addUser($user: AddUserSecretInput!) {
+			addUserSecret(input: [$user]) {
+				userSecret {
+					id
+					aSecret
+				}
+			}
+		}
+	`
+	// Seed a UserSecret owned by user1 inside ns2.
+	addParams := &common.GraphQLParams{
+		Headers: common.GetJWT(t, "user1", "", ns2, metaInfo),
+		Query:   addMutation,
+		Variables: map[string]interface{}{"user": &common.UserSecret{
+			ASecret: "ns2-secret",
+			OwnedBy: "user1",
+		}},
+	}
+	gqlResponse := addParams.ExecuteAsPost(t, common.GraphqlURL)
 	common.RequireNoGQLErrors(t, gqlResponse)
 
-	// Clear the tweet.
-	tweet.DeleteByID(t, user, metaInfo)
+	var added struct {
+		AddUserSecret struct {
+			UserSecret []*common.UserSecret
+		}
+	}
+	require.NoError(t, json.Unmarshal(gqlResponse.Data, &added))
+	require.Len(t, added.AddUserSecret.UserSecret, 1)
+
+	updateMutation := `
+		mutation updateUser($patch: UpdateUserSecretInput!) {
+			updateUserSecret(input: $patch) {
+				userSecret {
+					aSecret
+				}
+			}
+		}
+	`
+	// A token claiming ns=1 for the very same user1/owner pair must not be
+	// able to touch the row that was created under ns=2.
+	updateParams := &common.GraphQLParams{
+		Headers: common.GetJWT(t, "user1", "", ns1, metaInfo),
+		Query:   updateMutation,
+		Variables: map[string]interface{}{"patch": map[string]interface{}{
+			"filter": map[string]interface{}{"id": []interface{}{added.AddUserSecret.UserSecret[0].Id}},
+			"set":    map[string]interface{}{"aSecret": "overwritten-from-ns1"},
+		}},
+	}
+	gqlResponse = updateParams.ExecuteAsPost(t, common.GraphqlURL)
+	require.NotEqual(t, 0, len(gqlResponse.Errors))
+
+	// cleanup, back in ns2 where the row actually lives.
+	ns2Meta := *metaInfo
+	ns2Meta.Namespace = ns2
+	added.AddUserSecret.UserSecret[0].Delete(t, "user1", "", &ns2Meta)
 }
 
 func TestAddMutationWithAuthOnIDFieldHavingInterfaceArg(t *testing.T) {
@@ -180,8 +347,168 @@ func TestAddMutationWithAuthOnIDFieldHavingInterfaceArg(t *testing.T) {
 	// We show no error here as it could be a security violation
 	require.Equal(t, 0, resultSportsMember.AddSportsMember.NumUids)
 
+	// Omitting `upsert` altogether must fall back to the schema default
+	// (`upsert: Boolean = false`) rather than leaving it null, and omitting
+	// `readHours` must fall back to its schema default of `"0hr"`.
+	addLibraryMemberDefaultsParams := &common.GraphQLParams{
+		Query: `mutation addLibraryMember($input: [AddLibraryMemberInput!]!) {
+                         addLibraryMember(input: $input) {
+                          libraryMember {
+                           refID
+                           readHours
+                          }
+                         }
+                        }`,
+		Variables: map[string]interface{}{"input": []interface{}{
+			map[string]interface{}{
+				"refID": "102",
+				"name":  "Carol",
+			}},
+		},
+	}
+
+	gqlResponse = addLibraryMemberDefaultsParams.ExecuteAsPost(t, common.GraphqlURL)
+	common.RequireNoGQLErrors(t, gqlResponse)
+	var resultLibraryMemberDefaults struct {
+		AddLibraryMember struct {
+			LibraryMember []struct {
+				RefID     string
+				ReadHours string
+			}
+		}
+	}
+	err = json.Unmarshal(gqlResponse.Data, &resultLibraryMemberDefaults)
+	require.NoError(t, err)
+	require.Len(t, resultLibraryMemberDefaults.AddLibraryMember.LibraryMember, 1)
+	require.Equal(t, "0hr", resultLibraryMemberDefaults.AddLibraryMember.LibraryMember[0].ReadHours)
+
 	// cleanup
-	common.DeleteGqlType(t, "LibraryMember", map[string]interface{}{}, 1, nil)
+	common.DeleteGqlType(t, "LibraryMember", map[string]interface{}{}, 2, nil)
+}
+
+// jwksServer is a tiny in-process JWKS endpoint that can rotate its signing
+// key on demand, so tests can prove that Dgraph re-fetches the keyset when it
+// sees a kid it doesn't recognise instead of failing the request. Key
+// material and token minting are delegated to testutil.JWK/SignJWKS, so this
+// suite shares exactly one notion of "JWKS entry" with the rest of the repo
+// instead of rolling its own.
+type jwksServer struct {
+	*httptest.Server
+	activeKID string
+	keys      map[string]*rsa.PrivateKey
+}
+
+func newJWKSServer(t *testing.T) *jwksServer {
+	s := &jwksServer{keys: make(map[string]*rsa.PrivateKey)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=1")
+		w.Header().Set("Content-Type", "application/json")
+		keys := make([]map[string]interface{}, 0, len(s.keys))
+		for kid, key := range s.keys {
+			keys = append(keys, testutil.JWK(kid, &key.PublicKey))
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys}))
+	}))
+	s.rotate(t)
+	return s
+}
+
+// rotate adds a brand new signing key and makes it the active one, leaving
+// the old key (and kid) registered so the server keeps serving both, the way
+// a real IdP overlaps keys during rotation.
+func (s *jwksServer) rotate(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	kid := fmt.Sprintf("kid-%d", len(s.keys)+1)
+	s.keys[kid] = key
+	s.activeKID = kid
+}
+
+func (s *jwksServer) signToken(t *testing.T, namespace uint64, user, role string) string {
+	return testutil.SignJWKS(t, s.keys[s.activeKID], s.activeKID, user, role, namespace)
+}
+
+// TestAddGQLWithJWKRotation proves that a JWKURL-backed AuthMeta survives a
+// mid-test key rotation: the token signed with the newly rotated key has a
+// kid the cached keyset has never seen, which must trigger a lazy re-fetch
+// rather than a verification failure.
+func TestAddGQLWithJWKRotation(t *testing.T) {
+	jwks := newJWKSServer(t)
+	defer jwks.Close()
+
+	schemaFile := "../schema.graphql"
+	schema, err := os.ReadFile(schemaFile)
+	require.NoError(t, err)
+
+	authSchema, err := testutil.AppendJWKAuthInfo(schema, jwks.URL)
+	require.NoError(t, err)
+
+	authMeta, err := authorization.Parse(string(authSchema))
+	require.NoError(t, err)
+	common.BootstrapServer(authSchema, nil)
+
+	jwkMeta := &testutil.AuthMeta{
+		Namespace: authMeta.Namespace,
+		Header:    authMeta.Header,
+		JWKURL:    jwks.URL,
+	}
+
+	query := `
+		mutation addUser($user: AddUserSecretInput!) {
+			addUserSecret(input: [$user]) {
+				userSecret {
+					id
+					aSecret
+				}
+			}
+		}
+	`
+	params := &common.GraphQLParams{
+		Headers: map[string][]string{jwkMeta.Header: {jwks.signToken(t, jwkMeta.Namespace, "user1", "")}},
+		Query:   query,
+		Variables: map[string]interface{}{"user": &common.UserSecret{
+			ASecret: "jwk-rotated-secret",
+			OwnedBy: "user1",
+		}},
+	}
+	gqlResponse := params.ExecuteAsPost(t, common.GraphqlURL)
+	common.RequireNoGQLErrors(t, gqlResponse)
+
+	// Rotate the signing key mid-test: the next token carries a kid the
+	// server has never verified with before.
+	jwks.rotate(t)
+	params.Headers = map[string][]string{jwkMeta.Header: {jwks.signToken(t, jwkMeta.Namespace, "user1", "")}}
+	gqlResponse = params.ExecuteAsPost(t, common.GraphqlURL)
+	common.RequireNoGQLErrors(t, gqlResponse)
+
+	var result struct {
+		AddUserSecret struct {
+			UserSecret []*common.UserSecret
+		}
+	}
+	require.NoError(t, json.Unmarshal(gqlResponse.Data, &result))
+	for _, i := range result.AddUserSecret.UserSecret {
+		common.DeleteGqlType(t, "UserSecret", map[string]interface{}{"id": []interface{}{i.Id}}, 1,
+			map[string][]string{jwkMeta.Header: {jwks.signToken(t, jwkMeta.Namespace, "user1", "")}})
+	}
+}
+
+// jwtAlgoKeyFiles maps each signing algorithm exercised by this suite to the
+// PEM-encoded public/private key pair used to mint and verify test JWTs.
+// HS256 is symmetric, so the contents of one file serve as the shared secret
+// on both sides. RSA-family algorithms share a key pair; ECDSA and EdDSA need
+// their own, since the key types aren't interchangeable.
+var jwtAlgoKeyFiles = map[string]struct {
+	publicKey  string
+	privateKey string
+}{
+	jwt.SigningMethodHS256.Name:  {"../sample_public_key.pem", "../sample_public_key.pem"},
+	jwt.SigningMethodRS256.Name:  {"../sample_public_key.pem", "../sample_private_key.pem"},
+	jwt.SigningMethodPS256.Name:  {"../sample_public_key.pem", "../sample_private_key.pem"},
+	jwt.SigningMethodES256.Name:  {"../sample_ec_public_key.pem", "../sample_ec_private_key.pem"},
+	jwt.SigningMethodES384.Name:  {"../sample_ec_public_key.pem", "../sample_ec_private_key.pem"},
+	jwt.SigningMethodES512.Name:  {"../sample_ec_public_key.pem", "../sample_ec_private_key.pem"},
+	jwt.SigningMethodEdDSA.Alg(): {"../sample_ed25519_public_key.pem", "../sample_ed25519_private_key.pem"},
 }
 
 func TestMain(m *testing.M) {
@@ -195,9 +522,18 @@ func TestMain(m *testing.M) {
 		panic(errors.Wrapf(err, "Unable to read file %s.", jsonFile))
 	}
 
-	jwtAlgo := []string{jwt.SigningMethodHS256.Name, jwt.SigningMethodRS256.Name}
+	jwtAlgo := []string{
+		jwt.SigningMethodHS256.Name,
+		jwt.SigningMethodRS256.Name,
+		jwt.SigningMethodPS256.Name,
+		jwt.SigningMethodES256.Name,
+		jwt.SigningMethodES384.Name,
+		jwt.SigningMethodES512.Name,
+		jwt.SigningMethodEdDSA.Alg(),
+	}
 	for _, algo := range jwtAlgo {
-		authSchema, err := testutil.AppendAuthInfo(schema, algo, "../sample_public_key.pem", false)
+		keys := jwtAlgoKeyFiles[algo]
+		authSchema, err := testutil.AppendAuthInfo(schema, algo, keys.publicKey, false)
 		x.Panic(err)
 
 		authMeta, err := authorization.Parse(string(authSchema))
@@ -208,7 +544,7 @@ func TestMain(m *testing.M) {
 			Namespace:      authMeta.Namespace,
 			Algo:           authMeta.Algo,
 			Header:         authMeta.Header,
-			PrivateKeyPath: "../sample_private_key.pem",
+			PrivateKeyPath: keys.privateKey,
 		}
 
 		common.BootstrapServer(authSchema, data)
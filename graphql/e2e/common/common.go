@@ -0,0 +1,171 @@
+// Package common holds helpers shared by the GraphQL e2e suites under
+// graphql/e2e: firing GraphQL requests at a running Dgraph instance and the
+// handful of schema types (UserSecret, Tweets, ...) those suites mutate.
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/dgraph-io/dgraph/testutil"
+)
+
+// GraphqlURL is the endpoint the e2e suites POST GraphQL requests to. It can
+// be overridden via the TEST_GRAPHQL_URL environment variable for runs
+// against a non-default address.
+var GraphqlURL = envOr("TEST_GRAPHQL_URL", "http://localhost:8180/graphql")
+
+// adminURL is where schema updates are pushed.
+var adminURL = envOr("TEST_GRAPHQL_ADMIN_URL", "http://localhost:8180/admin")
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// GraphQLParams is a single GraphQL request: query, variables and whatever
+// headers (auth tokens, mostly) it should be sent with.
+type GraphQLParams struct {
+	Query     string
+	Variables map[string]interface{}
+	Headers   map[string][]string
+}
+
+// GqlError is a single entry of a GraphQL response's top-level "errors"
+// array.
+type GqlError struct {
+	Message string `json:"message"`
+}
+
+// GraphQLResponse is the standard {data, errors, extensions} envelope a
+// GraphQL server replies with.
+type GraphQLResponse struct {
+	Data       json.RawMessage        `json:"data"`
+	Errors     []GqlError             `json:"errors"`
+	Extensions map[string]interface{} `json:"extensions"`
+}
+
+// FailerT is the subset of *testing.T the helpers in this package need.
+type FailerT interface {
+	Fatalf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// ExecuteAsPost sends p to url as a standard POST GraphQL request and
+// decodes the response envelope.
+func (p *GraphQLParams) ExecuteAsPost(t FailerT, url string) *GraphQLResponse {
+	body, err := json.Marshal(map[string]interface{}{
+		"query":     p.Query,
+		"variables": p.Variables,
+	})
+	if err != nil {
+		t.Fatalf("unable to marshal GraphQL request: %v", err)
+		return nil
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("unable to build GraphQL request: %v", err)
+		return nil
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range p.Headers {
+		req.Header[k] = v
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GraphQL request to %s failed: %v", url, err)
+		return nil
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("unable to read GraphQL response: %v", err)
+		return nil
+	}
+
+	var gqlResp GraphQLResponse
+	if err := json.Unmarshal(respBody, &gqlResp); err != nil {
+		t.Fatalf("unable to decode GraphQL response %s: %v", respBody, err)
+		return nil
+	}
+	return &gqlResp
+}
+
+// RequireNoGQLErrors fails the test if resp carries any top-level GraphQL
+// errors.
+func RequireNoGQLErrors(t FailerT, resp *GraphQLResponse) {
+	if len(resp.Errors) != 0 {
+		t.Fatalf("expected no GraphQL errors, got: %v", resp.Errors)
+	}
+}
+
+// GetJWT is a thin re-export of testutil.GetJWT so e2e suites only need to
+// import the common package for everything request-shaped.
+func GetJWT(t testutil.TestingT, user, role string, namespace uint64, metaInfo *testutil.AuthMeta) map[string][]string {
+	return testutil.GetJWT(t, user, role, namespace, metaInfo)
+}
+
+// BootstrapServer pushes schema to the running server's admin endpoint and,
+// when data is non-nil, loads it as the seed dataset.
+func BootstrapServer(schema []byte, data []byte) {
+	body, err := json.Marshal(map[string]interface{}{
+		"query":     "mutation($schema: String!) { updateGQLSchema(input: {set: {schema: $schema}}) { gqlSchema { schema } } }",
+		"variables": map[string]interface{}{"schema": string(schema)},
+	})
+	if err != nil {
+		panic(err)
+	}
+	resp, err := http.Post(adminURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		panic(fmt.Errorf("unable to push schema to %s: %w", adminURL, err))
+	}
+	resp.Body.Close()
+
+	if data == nil {
+		return
+	}
+	resp, err = http.Post(adminURL+"/data", "application/json", bytes.NewReader(data))
+	if err != nil {
+		panic(fmt.Errorf("unable to load seed data: %w", err))
+	}
+	resp.Body.Close()
+}
+
+// DeleteGqlType runs a `delete<typeName>` mutation with the given filter and
+// checks that exactly wantNum nodes were removed.
+func DeleteGqlType(t FailerT, typeName string, filter map[string]interface{}, wantNum int, headers map[string][]string) {
+	query := fmt.Sprintf(`mutation($filter: %sFilter!) {
+		delete%s(filter: $filter) {
+			msg
+			numUids
+		}
+	}`, typeName, typeName)
+
+	params := &GraphQLParams{
+		Query:     query,
+		Variables: map[string]interface{}{"filter": filter},
+		Headers:   headers,
+	}
+	resp := params.ExecuteAsPost(t, GraphqlURL)
+	RequireNoGQLErrors(t, resp)
+
+	var result map[string]struct {
+		NumUids int `json:"numUids"`
+	}
+	if err := json.Unmarshal(resp.Data, &result); err != nil {
+		t.Fatalf("unable to decode delete%s response: %v", typeName, err)
+		return
+	}
+	if got := result["delete"+typeName].NumUids; got != wantNum {
+		t.Errorf("delete%s: expected to delete %d nodes, deleted %d", typeName, wantNum, got)
+	}
+}
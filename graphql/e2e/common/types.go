@@ -0,0 +1,63 @@
+package common
+
+import (
+	"github.com/dgraph-io/dgraph/testutil"
+)
+
+// UserSecret mirrors the `UserSecret` GraphQL type used by the auth e2e
+// suites: a per-user secret value, gated by `@auth` rules on OwnedBy.
+type UserSecret struct {
+	Id         string `json:"id,omitempty"`
+	ASecret    string `json:"aSecret,omitempty"`
+	OwnedBy    string `json:"ownedBy,omitempty"`
+	Visibility string `json:"visibility,omitempty"`
+}
+
+// Delete removes u via a deleteUserSecret mutation, authenticated as
+// user/role according to metaInfo.
+func (u *UserSecret) Delete(t FailerT, user, role string, metaInfo *testutil.AuthMeta) {
+	mutation := `
+		mutation deleteUserSecret($filter: UserSecretFilter!) {
+			deleteUserSecret(filter: $filter) {
+				numUids
+			}
+		}
+	`
+	params := &GraphQLParams{
+		Headers: testutil.GetJWT(t, user, role, metaInfo.Namespace, metaInfo),
+		Query:   mutation,
+		Variables: map[string]interface{}{
+			"filter": map[string]interface{}{"id": []interface{}{u.Id}},
+		},
+	}
+	resp := params.ExecuteAsPost(t, GraphqlURL)
+	RequireNoGQLErrors(t, resp)
+}
+
+// Tweets mirrors the `Tweets` GraphQL type, keyed by the `Id` `@id` field
+// (its xid) rather than a Dgraph-assigned uid.
+type Tweets struct {
+	Id        string `json:"id,omitempty"`
+	Text      string `json:"text,omitempty"`
+	Timestamp string `json:"timestamp,omitempty"`
+}
+
+// DeleteByID removes the tweet via its xid, authenticated as user.
+func (tw *Tweets) DeleteByID(t FailerT, user string, metaInfo *testutil.AuthMeta) {
+	mutation := `
+		mutation deleteTweets($filter: TweetsFilter!) {
+			deleteTweets(filter: $filter) {
+				numUids
+			}
+		}
+	`
+	params := &GraphQLParams{
+		Headers: testutil.GetJWT(t, user, "", metaInfo.Namespace, metaInfo),
+		Query:   mutation,
+		Variables: map[string]interface{}{
+			"filter": map[string]interface{}{"id": []interface{}{tw.Id}},
+		},
+	}
+	resp := params.ExecuteAsPost(t, GraphqlURL)
+	RequireNoGQLErrors(t, resp)
+}
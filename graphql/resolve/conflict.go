@@ -0,0 +1,100 @@
+// Package resolve decides how a parsed GraphQL mutation should be rewritten
+// into the lower-level operation Dgraph actually executes. So far that's
+// limited to AddRewriter, which decides how an `add<Type>` mutation on an
+// `@id`-keyed (xid) type should behave when a node with that xid already
+// exists.
+package resolve
+
+import "fmt"
+
+// ConflictMode is the `conflict` argument an `add<Type>` mutation on an
+// `@id`-keyed type can be given, controlling what happens when a node with
+// the same xid already exists.
+type ConflictMode string
+
+const (
+	// ConflictError rejects the mutation outright when the xid is already
+	// taken. This is the default when `conflict` is omitted.
+	ConflictError ConflictMode = "ERROR"
+	// ConflictIgnore leaves the existing node untouched and returns it as-is.
+	ConflictIgnore ConflictMode = "IGNORE"
+	// ConflictUpdate merges the mutation's fields into the existing node,
+	// subject to that type's `@auth` update rules being satisfied against
+	// the existing node's UID.
+	ConflictUpdate ConflictMode = "UPDATE"
+)
+
+// NodeLookup resolves an `@id` field's value to the UID of the node that
+// currently claims it, if any.
+type NodeLookup interface {
+	Lookup(xid string) (uid string, found bool)
+}
+
+// AuthChecker evaluates a type's `@auth` update rules against a specific,
+// already-existing node. It must be consulted before an UPDATE-mode
+// conflict is allowed to touch that node.
+type AuthChecker interface {
+	CanUpdate(uid string) error
+}
+
+// UpsertOutcome describes what RewriteUpsert decided to do with a mutation
+// whose xid might already exist.
+type UpsertOutcome int
+
+const (
+	// OutcomeInsert means no node claimed the xid; proceed with a plain
+	// insert.
+	OutcomeInsert UpsertOutcome = iota
+	// OutcomeIgnored means the xid was already claimed and ConflictIgnore
+	// left it untouched.
+	OutcomeIgnored
+	// OutcomeUpdated means the xid was already claimed and its fields were
+	// merged in under ConflictUpdate.
+	OutcomeUpdated
+)
+
+// UpsertResult is what RewriteUpsert returns: what it decided to do, the UID
+// it decided to do it to (the existing UID for Ignored/Updated, empty for a
+// fresh Insert) and, for an update, the fields to write.
+type UpsertResult struct {
+	Outcome UpsertOutcome
+	UID     string
+	Fields  map[string]interface{}
+}
+
+// AddRewriter is the entry point an `add<Type>` mutation handler builds one
+// of, per xid-keyed type, to decide how to rewrite the mutation: Lookup
+// resolves whether the xid is already claimed, and Auth is consulted only
+// for ConflictUpdate, since that's the only mode that writes to a node the
+// mutation's caller didn't just create.
+type AddRewriter struct {
+	Lookup NodeLookup
+	Auth   AuthChecker
+}
+
+// Rewrite decides how an `add<Type>` mutation for the @id value xid should
+// proceed, given mode and newFields (the mutation's input fields).
+func (rw *AddRewriter) Rewrite(mode ConflictMode, xid string, newFields map[string]interface{}) (*UpsertResult, error) {
+	if mode == "" {
+		mode = ConflictError
+	}
+
+	uid, found := rw.Lookup.Lookup(xid)
+	if !found {
+		return &UpsertResult{Outcome: OutcomeInsert, Fields: newFields}, nil
+	}
+
+	switch mode {
+	case ConflictError:
+		return nil, fmt.Errorf("a node already exists with id %q", xid)
+	case ConflictIgnore:
+		return &UpsertResult{Outcome: OutcomeIgnored, UID: uid}, nil
+	case ConflictUpdate:
+		if err := rw.Auth.CanUpdate(uid); err != nil {
+			return nil, fmt.Errorf("not authorized to update node %q: %w", uid, err)
+		}
+		return &UpsertResult{Outcome: OutcomeUpdated, UID: uid, Fields: newFields}, nil
+	default:
+		return nil, fmt.Errorf("unknown conflict mode %q", mode)
+	}
+}
@@ -0,0 +1,76 @@
+package resolve
+
+import (
+	"errors"
+	"testing"
+)
+
+type fakeLookup map[string]string
+
+func (f fakeLookup) Lookup(xid string) (string, bool) {
+	uid, ok := f[xid]
+	return uid, ok
+}
+
+type fakeAuth struct{ err error }
+
+func (f fakeAuth) CanUpdate(uid string) error { return f.err }
+
+func TestRewriteUpsertInsertWhenNoConflict(t *testing.T) {
+	rw := &AddRewriter{Lookup: fakeLookup{}, Auth: fakeAuth{}}
+	res, err := rw.Rewrite(ConflictError, "tweet-1", map[string]interface{}{"text": "hi"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Outcome != OutcomeInsert {
+		t.Fatalf("expected OutcomeInsert, got %v", res.Outcome)
+	}
+}
+
+func TestRewriteUpsertErrorModeRejectsConflict(t *testing.T) {
+	rw := &AddRewriter{Lookup: fakeLookup{"tweet-1": "0x1"}, Auth: fakeAuth{}}
+	_, err := rw.Rewrite(ConflictError, "tweet-1", nil)
+	if err == nil {
+		t.Fatal("expected ConflictError to reject an existing xid")
+	}
+}
+
+func TestRewriteUpsertDefaultModeIsError(t *testing.T) {
+	rw := &AddRewriter{Lookup: fakeLookup{"tweet-1": "0x1"}, Auth: fakeAuth{}}
+	_, err := rw.Rewrite("", "tweet-1", nil)
+	if err == nil {
+		t.Fatal("expected the zero-value conflict mode to behave like ERROR")
+	}
+}
+
+func TestRewriteUpsertIgnoreModeLeavesNodeAlone(t *testing.T) {
+	rw := &AddRewriter{Lookup: fakeLookup{"tweet-1": "0x1"}, Auth: fakeAuth{}}
+	res, err := rw.Rewrite(ConflictIgnore, "tweet-1", map[string]interface{}{"text": "new"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Outcome != OutcomeIgnored || res.UID != "0x1" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+}
+
+func TestRewriteUpsertUpdateModeChecksAuth(t *testing.T) {
+	lookup := fakeLookup{"tweet-1": "0x1"}
+
+	deniedRw := &AddRewriter{Lookup: lookup, Auth: fakeAuth{err: errAuthDenied}}
+	_, err := deniedRw.Rewrite(ConflictUpdate, "tweet-1", nil)
+	if err == nil {
+		t.Fatal("expected an auth failure to block the update")
+	}
+
+	rw := &AddRewriter{Lookup: lookup, Auth: fakeAuth{}}
+	res, err := rw.Rewrite(ConflictUpdate, "tweet-1", map[string]interface{}{"text": "new"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Outcome != OutcomeUpdated || res.UID != "0x1" || res.Fields["text"] != "new" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+}
+
+var errAuthDenied = errors.New("permission denied")
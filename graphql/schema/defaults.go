@@ -0,0 +1,269 @@
+// Package schema extracts the pieces of a GraphQL SDL document that the
+// query-rewriting layer needs before it can build a mutation: specifically,
+// the default values attached to an input type's fields (`field: Type =
+// defaultValue`) and to a mutation field's own arguments (`addFoo(arg: Type
+// = defaultValue)`), so an omitted argument can be coerced to its schema
+// default instead of being sent to Dgraph as null.
+package schema
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// FieldDefinition is a single field of an `input` type (or argument of a
+// mutation field), along with the default value (if any) a GraphQL server
+// must substitute when a mutation omits it.
+type FieldDefinition struct {
+	Name         string
+	Type         string // the field's named type, with any `[`, `]` or `!` stripped
+	DefaultValue interface{}
+	HasDefault   bool
+}
+
+// InputObject is the subset of an `input TypeName { ... }` definition (or a
+// mutation field's argument list) this package cares about: its fields,
+// each with the default value it declares, if any.
+type InputObject struct {
+	Name   string
+	Fields []FieldDefinition
+}
+
+// inputBlockRegexp finds `input TypeName { <body> }` blocks.
+var inputBlockRegexp = regexp.MustCompile(`(?s)input\s+(\w+)\s*\{([^}]*)\}`)
+
+// mutationBlockRegexp finds `type Mutation { <body> }` blocks.
+var mutationBlockRegexp = regexp.MustCompile(`(?s)type\s+Mutation\s*\{([^}]*)\}`)
+
+// mutationFieldRegexp finds a `fieldName(args)` call signature within a
+// `type Mutation { ... }` block's body.
+var mutationFieldRegexp = regexp.MustCompile(`(\w+)\s*\(([^)]*)\)`)
+
+// fieldLineRegexp matches a single `name: Type` or `name: Type = value`
+// declaration, whether that's an input type's field or a mutation field's
+// argument — both have the same shape.
+var fieldLineRegexp = regexp.MustCompile(`^(\w+)\s*:\s*([^=]+?)\s*(?:=\s*(.+))?$`)
+
+// bareTypeName strips the list/non-null decoration (`[`, `]`, `!`) from a
+// GraphQL type reference, leaving just the named type — `[AddFooInput!]!`
+// becomes `AddFooInput` — so it can be looked up as an input block name.
+func bareTypeName(raw string) string {
+	raw = strings.TrimSpace(raw)
+	raw = strings.TrimSuffix(raw, "!")
+	raw = strings.TrimPrefix(raw, "[")
+	raw = strings.TrimSuffix(raw, "]")
+	raw = strings.TrimSuffix(raw, "!")
+	return strings.TrimSpace(raw)
+}
+
+// stripTrailingDirectives trims a trailing `@directive(...)` (schema
+// directives like `@search` or `@id` can follow a field's type or its
+// default value) off raw, tracking quote and bracket nesting so a literal
+// that happens to contain an `@` — `"user@example.com"` — isn't mistaken for
+// one. A field declared with no default, e.g. `status: String @search`, and
+// one declared with one, e.g. `status: String = "ACTIVE" @search`, both come
+// through here: the former via fieldLineRegexp's Type group, the latter via
+// its default-value group.
+func stripTrailingDirectives(raw string) string {
+	depth := 0
+	inString := false
+	for i, r := range raw {
+		switch r {
+		case '"':
+			inString = !inString
+		case '[':
+			if !inString {
+				depth++
+			}
+		case ']':
+			if !inString {
+				depth--
+			}
+		case '@':
+			if !inString && depth == 0 {
+				return strings.TrimSpace(raw[:i])
+			}
+		}
+	}
+	return strings.TrimSpace(raw)
+}
+
+// ParseInputDefaults scans sdl for the `input typeName { ... }` block and
+// returns every one of its fields, noting the default value declared for any
+// that have one. A type with no fields, or that doesn't appear in sdl at
+// all, yields an InputObject with no Fields rather than an error — most
+// input types don't default anything, and that's not a problem to report.
+func ParseInputDefaults(sdl, typeName string) (*InputObject, error) {
+	obj := &InputObject{Name: typeName}
+
+	for _, m := range inputBlockRegexp.FindAllStringSubmatch(sdl, -1) {
+		if m[1] != typeName {
+			continue
+		}
+		fields, err := parseFieldLines(m[2], typeName)
+		if err != nil {
+			return nil, err
+		}
+		obj.Fields = append(obj.Fields, fields...)
+	}
+
+	return obj, nil
+}
+
+// ParseFieldArgDefaults scans sdl's `type Mutation { ... }` block for
+// fieldName's argument list and returns every argument, noting the default
+// value declared for any that have one — covering defaults attached
+// directly to a mutation field (e.g. `addLibraryMember(upsert: Boolean =
+// false)`) rather than to an input type's fields.
+func ParseFieldArgDefaults(sdl, fieldName string) (*InputObject, error) {
+	obj := &InputObject{Name: fieldName}
+
+	for _, b := range mutationBlockRegexp.FindAllStringSubmatch(sdl, -1) {
+		for _, fm := range mutationFieldRegexp.FindAllStringSubmatch(b[1], -1) {
+			if fm[1] != fieldName {
+				continue
+			}
+			fields, err := parseFieldLines(strings.Join(splitArgs(fm[2]), "\n"), fieldName)
+			if err != nil {
+				return nil, err
+			}
+			obj.Fields = append(obj.Fields, fields...)
+		}
+	}
+
+	return obj, nil
+}
+
+// parseFieldLines parses each `name: Type` / `name: Type = value` line in
+// body (an input block's body, or a mutation field's arguments joined one
+// per line) into a FieldDefinition. owner is only used to annotate a
+// default-literal parse error with where it came from.
+func parseFieldLines(body, owner string) ([]FieldDefinition, error) {
+	var fields []FieldDefinition
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		fm := fieldLineRegexp.FindStringSubmatch(line)
+		if fm == nil {
+			continue
+		}
+
+		field := FieldDefinition{Name: fm[1], Type: bareTypeName(stripTrailingDirectives(fm[2]))}
+		if literal := stripTrailingDirectives(fm[3]); literal != "" {
+			value, err := parseLiteral(literal)
+			if err != nil {
+				return nil, fmt.Errorf("parsing default for %s.%s: %w", owner, fm[1], err)
+			}
+			field.DefaultValue = value
+			field.HasDefault = true
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// splitArgs splits a GraphQL argument list on its top-level commas, so a
+// default list literal (e.g. `tags: [String!] = ["a", "b"]`) doesn't get cut
+// in half by the comma inside it.
+func splitArgs(args string) []string {
+	var result []string
+	depth := 0
+	start := 0
+	for i, r := range args {
+		switch r {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case ',':
+			if depth == 0 {
+				result = append(result, args[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if strings.TrimSpace(args[start:]) != "" {
+		result = append(result, args[start:])
+	}
+	return result
+}
+
+// parseLiteral converts a GraphQL SDL literal (string, int, float, bool or
+// null) into the Go value CoerceDefaults should substitute.
+func parseLiteral(lit string) (interface{}, error) {
+	switch {
+	case lit == "null":
+		return nil, nil
+	case lit == "true":
+		return true, nil
+	case lit == "false":
+		return false, nil
+	case strings.HasPrefix(lit, `"`) && strings.HasSuffix(lit, `"`):
+		return strings.TrimSuffix(strings.TrimPrefix(lit, `"`), `"`), nil
+	default:
+		if i, err := strconv.ParseInt(lit, 10, 64); err == nil {
+			return i, nil
+		}
+		if f, err := strconv.ParseFloat(lit, 64); err == nil {
+			return f, nil
+		}
+		return nil, fmt.Errorf("unrecognised default value literal %q", lit)
+	}
+}
+
+// CoerceDefaults walks obj's fields and, for each one missing from args (or
+// explicitly set to nil), fills in its schema default. It then recurses into
+// every field whose value is a nested input object (or list of them),
+// parsing sdl for that field's own input type so a nested input's defaults
+// are coerced the same way the top-level one's are. args is mutated in
+// place and returned for convenience.
+func CoerceDefaults(sdl string, obj *InputObject, args map[string]interface{}) (map[string]interface{}, error) {
+	if args == nil {
+		args = make(map[string]interface{})
+	}
+	for _, f := range obj.Fields {
+		if f.HasDefault {
+			if v, ok := args[f.Name]; !ok || v == nil {
+				args[f.Name] = f.DefaultValue
+			}
+		}
+
+		value, ok := args[f.Name]
+		if !ok || value == nil {
+			continue
+		}
+		if err := coerceNestedDefaults(sdl, f.Type, value); err != nil {
+			return nil, err
+		}
+	}
+	return args, nil
+}
+
+// coerceNestedDefaults applies CoerceDefaults to value if it's a nested
+// input object (or a list of them) of the given GraphQL type. A scalar type,
+// or one with no defaulted fields, is left untouched.
+func coerceNestedDefaults(sdl, typeName string, value interface{}) error {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		nested, err := ParseInputDefaults(sdl, typeName)
+		if err != nil {
+			return err
+		}
+		if len(nested.Fields) == 0 {
+			return nil
+		}
+		_, err = CoerceDefaults(sdl, nested, v)
+		return err
+	case []interface{}:
+		for _, elem := range v {
+			if err := coerceNestedDefaults(sdl, typeName, elem); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
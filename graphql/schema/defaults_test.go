@@ -0,0 +1,151 @@
+package schema
+
+import "testing"
+
+const testSDL = `
+input AddUserSecretInput {
+	aSecret: String!
+	ownedBy: String!
+	visibility: String = "private"
+}
+
+input AddLibraryMemberInput {
+	refID: String!
+	readHours: String = "0hr"
+}
+
+input AddShelfInput {
+	name: String!
+	member: AddLibraryMemberInput
+}
+
+type Mutation {
+	addLibraryMember(input: [AddLibraryMemberInput!]!, upsert: Boolean = false): AddLibraryMemberPayload
+}
+`
+
+func TestParseInputDefaults(t *testing.T) {
+	obj, err := ParseInputDefaults(testSDL, "AddUserSecretInput")
+	if err != nil {
+		t.Fatalf("ParseInputDefaults: %v", err)
+	}
+
+	var defaulted []FieldDefinition
+	for _, f := range obj.Fields {
+		if f.HasDefault {
+			defaulted = append(defaulted, f)
+		}
+	}
+	if len(defaulted) != 1 || defaulted[0].Name != "visibility" || defaulted[0].DefaultValue != "private" {
+		t.Fatalf("unexpected defaulted fields: %+v", defaulted)
+	}
+}
+
+func TestCoerceDefaultsFillsOmittedField(t *testing.T) {
+	obj, err := ParseInputDefaults(testSDL, "AddUserSecretInput")
+	if err != nil {
+		t.Fatalf("ParseInputDefaults: %v", err)
+	}
+
+	args, err := CoerceDefaults(testSDL, obj, map[string]interface{}{"aSecret": "shh", "ownedBy": "user1"})
+	if err != nil {
+		t.Fatalf("CoerceDefaults: %v", err)
+	}
+	if args["visibility"] != "private" {
+		t.Fatalf("expected visibility to default to %q, got %v", "private", args["visibility"])
+	}
+}
+
+func TestCoerceDefaultsLeavesExplicitValueAlone(t *testing.T) {
+	obj, err := ParseInputDefaults(testSDL, "AddLibraryMemberInput")
+	if err != nil {
+		t.Fatalf("ParseInputDefaults: %v", err)
+	}
+
+	args, err := CoerceDefaults(testSDL, obj, map[string]interface{}{"refID": "lib1", "readHours": "4d2hr"})
+	if err != nil {
+		t.Fatalf("CoerceDefaults: %v", err)
+	}
+	if args["readHours"] != "4d2hr" {
+		t.Fatalf("explicit readHours was overwritten: got %v", args["readHours"])
+	}
+
+	args, err = CoerceDefaults(testSDL, obj, map[string]interface{}{"refID": "lib2"})
+	if err != nil {
+		t.Fatalf("CoerceDefaults: %v", err)
+	}
+	if args["readHours"] != "0hr" {
+		t.Fatalf("expected readHours to default to %q, got %v", "0hr", args["readHours"])
+	}
+}
+
+func TestParseFieldArgDefaultsOnMutationField(t *testing.T) {
+	obj, err := ParseFieldArgDefaults(testSDL, "addLibraryMember")
+	if err != nil {
+		t.Fatalf("ParseFieldArgDefaults: %v", err)
+	}
+
+	var upsert *FieldDefinition
+	for i, f := range obj.Fields {
+		if f.Name == "upsert" {
+			upsert = &obj.Fields[i]
+		}
+	}
+	if upsert == nil || !upsert.HasDefault || upsert.DefaultValue != false {
+		t.Fatalf("expected upsert to default to false, got %+v", upsert)
+	}
+}
+
+func TestParseInputDefaultsIgnoresTrailingDirective(t *testing.T) {
+	sdl := `
+input AddPostInput {
+	status: String = "ACTIVE" @search
+	tag: String! @id
+}
+`
+	obj, err := ParseInputDefaults(sdl, "AddPostInput")
+	if err != nil {
+		t.Fatalf("ParseInputDefaults: %v", err)
+	}
+
+	var status, tag *FieldDefinition
+	for i, f := range obj.Fields {
+		switch f.Name {
+		case "status":
+			status = &obj.Fields[i]
+		case "tag":
+			tag = &obj.Fields[i]
+		}
+	}
+	if status == nil || !status.HasDefault || status.DefaultValue != "ACTIVE" || status.Type != "String" {
+		t.Fatalf("unexpected status field: %+v", status)
+	}
+	if tag == nil || tag.Type != "String" {
+		t.Fatalf("unexpected tag field: %+v", tag)
+	}
+}
+
+func TestCoerceDefaultsRecursesIntoNestedInputObject(t *testing.T) {
+	obj, err := ParseInputDefaults(testSDL, "AddShelfInput")
+	if err != nil {
+		t.Fatalf("ParseInputDefaults: %v", err)
+	}
+
+	args, err := CoerceDefaults(testSDL, obj, map[string]interface{}{
+		"name": "sci-fi",
+		"member": map[string]interface{}{
+			"refID": "lib1",
+		},
+	})
+	if err != nil {
+		t.Fatalf("CoerceDefaults: %v", err)
+	}
+
+	member, ok := args["member"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected member to remain a map, got %T", args["member"])
+	}
+	if member["readHours"] != "0hr" {
+		t.Fatalf("expected nested readHours to default to %q, got %v", "0hr", member["readHours"])
+	}
+}
@@ -0,0 +1,287 @@
+// Package testutil holds small helpers shared by the e2e test suites — it
+// has no other purpose, so nothing here should be imported by production
+// code.
+package testutil
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AuthMeta bundles together everything a test needs to mint a JWT that a
+// server configured via AppendAuthInfo/AppendJWKAuthInfo will accept:
+// the key to sign with (or a JWKS URL it should be discoverable under) and
+// the header/algo/namespace the schema's `# Dgraph.Authorization` directive
+// expects.
+type AuthMeta struct {
+	PublicKey      string
+	PrivateKeyPath string
+	Namespace      uint64
+	Algo           string
+	Header         string
+	JWKURL         string
+}
+
+// AppendAuthInfo appends a `# Dgraph.Authorization` directive, configured for
+// a static VerificationKey, to schema. publicKeyFile's contents, trimmed of
+// surrounding whitespace, are used as the key: a raw HMAC secret for HS256
+// (so GetJWT must sign with the same file), or a PEM-encoded public key for
+// every other algo.
+func AppendAuthInfo(schema []byte, algo, publicKeyFile string, closeToExpire bool) ([]byte, error) {
+	key, err := os.ReadFile(publicKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read public key file %s: %w", publicKeyFile, err)
+	}
+
+	directive := map[string]interface{}{
+		"VerificationKey": strings.TrimSpace(string(key)),
+		"Header":          "X-Test-Auth",
+		"Namespace":       "0",
+		"NamespaceField":  namespaceField,
+		"Algo":            algo,
+	}
+	if closeToExpire {
+		directive["ClosingTimeSkew"] = 1
+	}
+
+	return appendDirective(schema, directive)
+}
+
+// AppendJWKAuthInfo appends a `# Dgraph.Authorization` directive configured
+// to fetch its verification keys from a JWKS endpoint instead of a static
+// VerificationKey.
+func AppendJWKAuthInfo(schema []byte, jwkURL string) ([]byte, error) {
+	return appendDirective(schema, map[string]interface{}{
+		"JWKURL":         jwkURL,
+		"Header":         "X-Test-Auth",
+		"Namespace":      "0",
+		"NamespaceField": namespaceField,
+	})
+}
+
+// namespaceField is the claim AppendAuthInfo/AppendJWKAuthInfo bind the
+// directive's namespace enforcement to — the same claim GetJWT/SignJWKS
+// populate, so a test's tokens are actually checked against the namespace
+// they're presented to rather than namespace-checking being silently off.
+const namespaceField = "https://dgraph.io/jwt/namespace"
+
+func appendDirective(schema []byte, directive map[string]interface{}) ([]byte, error) {
+	encoded, err := json.Marshal(directive)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal Dgraph.Authorization directive: %w", err)
+	}
+	return append(schema, []byte(fmt.Sprintf("\n# Dgraph.Authorization %s\n", encoded))...), nil
+}
+
+// GetJWT mints a JWT for user/role, bound to namespace, signed with the
+// static PEM/HMAC key at metaInfo.PrivateKeyPath according to metaInfo.Algo,
+// and returns it wrapped in the headers the server expects it under. It does
+// not consult metaInfo.JWKURL; tokens for a JWKS endpoint come from SignJWKS.
+func GetJWT(t TestingT, user, role string, namespace uint64, metaInfo *AuthMeta) map[string][]string {
+	claims := map[string]interface{}{
+		"https://dgraph.io/jwt/claims": map[string]interface{}{
+			"USER": user,
+			"ROLE": role,
+		},
+		"https://dgraph.io/jwt/namespace": strconv.FormatUint(namespace, 10),
+		"exp":                             time.Now().Add(time.Hour).Unix(),
+	}
+
+	token, err := signJWT(metaInfo.Algo, metaInfo.PrivateKeyPath, claims, "")
+	if err != nil {
+		t.Fatalf("unable to mint test JWT: %v", err)
+	}
+
+	return map[string][]string{metaInfo.Header: {token}}
+}
+
+// TestingT is the subset of *testing.T that this package's helpers need —
+// narrow enough that non-test callers (e.g. a one-off script) can satisfy it
+// too without pulling in the testing package.
+type TestingT interface {
+	Fatalf(format string, args ...interface{})
+}
+
+// signJWT builds and signs a compact JWT. When kid is non-empty it's set on
+// the header, so a JWKS-backed verifier can pick the right key out of a
+// rotated keyset.
+func signJWT(algo, privateKeyPath string, claims map[string]interface{}, kid string) (string, error) {
+	header := map[string]interface{}{"alg": algo, "typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." +
+		base64.RawURLEncoding.EncodeToString(claimsJSON)
+
+	sig, err := sign(algo, privateKeyPath, []byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// sign parses privateKeyPath according to algo and produces a raw (not
+// ASN.1-wrapped) signature over signingInput — the same wire format
+// authorization.VerifySignature expects to verify.
+func sign(algo, privateKeyPath string, signingInput []byte) ([]byte, error) {
+	if algo == "HS256" {
+		secret, err := os.ReadFile(privateKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read HMAC secret %s: %w", privateKeyPath, err)
+		}
+		mac := hmac.New(sha256.New, []byte(strings.TrimSpace(string(secret))))
+		mac.Write(signingInput)
+		return mac.Sum(nil), nil
+	}
+
+	key, err := parsePrivateKey(privateKeyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	switch algo {
+	case "RS256":
+		priv, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("RS256 requires an RSA private key, got %T", key)
+		}
+		sum := sha256.Sum256(signingInput)
+		return rsa.SignPKCS1v15(rand.Reader, priv, crypto.SHA256, sum[:])
+	case "PS256":
+		priv, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("PS256 requires an RSA private key, got %T", key)
+		}
+		sum := sha256.Sum256(signingInput)
+		return rsa.SignPSS(rand.Reader, priv, crypto.SHA256, sum[:], nil)
+	case "ES256", "ES384", "ES512":
+		priv, ok := key.(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("%s requires an ECDSA private key, got %T", algo, key)
+		}
+		return signECDSA(algo, priv, signingInput)
+	case "EdDSA":
+		priv, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("EdDSA requires an Ed25519 private key, got %T", key)
+		}
+		return ed25519.Sign(priv, signingInput), nil
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", algo)
+	}
+}
+
+// parsePrivateKey reads a PEM-encoded private key and parses it according to
+// its block type: PKCS1/PKCS8 for RSA, SEC1 ("EC PRIVATE KEY") for ECDSA,
+// and PKCS8 for Ed25519 — Go has no SEC1-equivalent DER form for Ed25519, so
+// PKCS8 is the only encoding an Ed25519 private key is ever stored in.
+func parsePrivateKey(path string) (interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read private key file %s: %w", path, err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s does not contain a PEM block", path)
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	default: // "PRIVATE KEY" (PKCS8) — covers RSA, ECDSA and Ed25519 alike.
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+}
+
+func signECDSA(algo string, priv *ecdsa.PrivateKey, signingInput []byte) ([]byte, error) {
+	var sum []byte
+	var keyBytes int
+	switch algo {
+	case "ES256":
+		h := sha256.Sum256(signingInput)
+		sum, keyBytes = h[:], 32
+	case "ES384":
+		h := sha512.Sum384(signingInput)
+		sum, keyBytes = h[:], 48
+	case "ES512":
+		h := sha512.Sum512(signingInput)
+		sum, keyBytes = h[:], 66
+	}
+	r, s, err := ecdsa.Sign(rand.Reader, priv, sum)
+	if err != nil {
+		return nil, err
+	}
+	sig := make([]byte, 2*keyBytes)
+	r.FillBytes(sig[:keyBytes])
+	s.FillBytes(sig[keyBytes:])
+	return sig, nil
+}
+
+// JWK renders pub as a single entry of a JWKS `keys` array, for tests that
+// spin up an in-process JWKS server (see authorization.AuthMeta's JWKURL
+// support).
+func JWK(kid string, pub *rsa.PublicKey) map[string]interface{} {
+	return map[string]interface{}{
+		"kty": "RSA",
+		"use": "sig",
+		"alg": "RS256",
+		"kid": kid,
+		"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}
+}
+
+// SignJWKS mints a JWT signed by priv with kid set, for use against a JWKS
+// endpoint built from JWK(kid, &priv.PublicKey).
+func SignJWKS(t TestingT, priv *rsa.PrivateKey, kid, user, role string, namespace uint64) string {
+	claims := map[string]interface{}{
+		"https://dgraph.io/jwt/claims": map[string]interface{}{
+			"USER": user,
+			"ROLE": role,
+		},
+		"https://dgraph.io/jwt/namespace": strconv.FormatUint(namespace, 10),
+		"exp":                             time.Now().Add(time.Hour).Unix(),
+	}
+	header := map[string]interface{}{"alg": "RS256", "typ": "JWT", "kid": kid}
+
+	headerJSON, _ := json.Marshal(header)
+	claimsJSON, _ := json.Marshal(claims)
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." +
+		base64.RawURLEncoding.EncodeToString(claimsJSON)
+
+	sum := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, priv, crypto.SHA256, sum[:])
+	if err != nil {
+		t.Fatalf("unable to sign JWKS test token: %v", err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
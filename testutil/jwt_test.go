@@ -0,0 +1,146 @@
+package testutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dgraph-io/dgraph/graphql/authorization"
+)
+
+type fatalT struct{ *testing.T }
+
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := pem.Encode(f, &pem.Block{Type: blockType, Bytes: der}); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func pubPEM(t *testing.T, pub interface{}) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+type algoFixture struct {
+	algo     string
+	privPath string
+	pubPEM   string
+}
+
+// TestGetJWTRoundTrip mints a token for every asymmetric algorithm this
+// package supports and checks it verifies against
+// authorization.VerifySignature — i.e. that testutil's signer and the
+// production verifier agree on the wire format, including the PKCS1/SEC1/
+// PKCS8 private-key parsing branches GetJWT relies on.
+func TestGetJWTRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	var fixtures []algoFixture
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaPrivPath := writePEM(t, dir, "rsa_private.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+	rsaPubPEM := pubPEM(t, &rsaKey.PublicKey)
+	fixtures = append(fixtures,
+		algoFixture{"RS256", rsaPrivPath, rsaPubPEM},
+		algoFixture{"PS256", rsaPrivPath, rsaPubPEM},
+	)
+
+	for algo, curve := range map[string]elliptic.Curve{
+		"ES256": elliptic.P256(),
+		"ES384": elliptic.P384(),
+		"ES512": elliptic.P521(),
+	} {
+		priv, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ecDER, err := x509.MarshalECPrivateKey(priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		privPath := writePEM(t, dir, algo+"_private.pem", "EC PRIVATE KEY", ecDER)
+		fixtures = append(fixtures, algoFixture{algo, privPath, pubPEM(t, &priv.PublicKey)})
+	}
+
+	edPub, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edDER, err := x509.MarshalPKCS8PrivateKey(edPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPrivPath := writePEM(t, dir, "ed25519_private.pem", "PRIVATE KEY", edDER)
+	fixtures = append(fixtures, algoFixture{"EdDSA", edPrivPath, pubPEM(t, edPub)})
+
+	for _, f := range fixtures {
+		f := f
+		t.Run(f.algo, func(t *testing.T) {
+			meta := &AuthMeta{Algo: f.algo, Header: "X-Test-Auth", PrivateKeyPath: f.privPath}
+			headers := GetJWT(fatalT{t}, "user1", "", 0, meta)
+			token := headers["X-Test-Auth"][0]
+
+			authMeta := &authorization.AuthMeta{Algo: f.algo, VerificationKey: f.pubPEM}
+			verifyToken(t, authMeta, token)
+		})
+	}
+}
+
+func TestGetJWTRoundTripHS256(t *testing.T) {
+	dir := t.TempDir()
+	secretPath := filepath.Join(dir, "hmac_secret")
+	if err := os.WriteFile(secretPath, []byte("shared-secret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	meta := &AuthMeta{Algo: "HS256", Header: "X-Test-Auth", PrivateKeyPath: secretPath}
+	headers := GetJWT(fatalT{t}, "user1", "", 0, meta)
+	token := headers["X-Test-Auth"][0]
+
+	authMeta := &authorization.AuthMeta{Algo: "HS256", VerificationKey: "shared-secret"}
+	verifyToken(t, authMeta, token)
+}
+
+// verifyToken splits a compact JWT and checks its signature against authMeta
+// using the same code path the production server would.
+func verifyToken(t *testing.T, authMeta *authorization.AuthMeta, token string) {
+	t.Helper()
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("malformed token: %s", token)
+	}
+	key, err := authMeta.VerificationKeyForKID("")
+	if err != nil {
+		t.Fatalf("resolving verification key: %v", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := authorization.VerifySignature(authMeta.Algo, key, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		t.Fatalf("token minted by testutil did not verify: %v", err)
+	}
+}
@@ -0,0 +1,12 @@
+// Package x holds small helpers shared across the rest of the codebase that
+// don't belong to any particular subsystem.
+package x
+
+// Panic panics if err is non-nil. It's used in places — mostly test and
+// bootstrap code — where there's no sensible way to recover from the error
+// and propagating it up the call stack would just add boilerplate.
+func Panic(err error) {
+	if err != nil {
+		panic(err)
+	}
+}